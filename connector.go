@@ -0,0 +1,55 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "fmt"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* per-connection bootstrap pragmas */
+
+// pragmaConnector wraps a driver.Connector so every connection it hands out
+// has activeDialect's BootstrapPragmas applied before it's usable. A pooled
+// db.Exec can't guarantee that: the pool is free to open new connections at
+// any time, and SQLite's foreign_keys pragma (like anything else
+// BootstrapPragmas returns) is per-connection state, so a pragma applied to
+// one connection says nothing about the rest of the pool.
+type pragmaConnector struct {
+    driver.Connector
+}
+
+func (c pragmaConnector) Connect(ctx context.Context) (driver.Conn, error) {
+    conn, err := c.Connector.Connect(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    for _, pragma := range activeDialect.BootstrapPragmas() {
+        execer, ok := conn.(driver.ExecerContext)
+        if !ok {
+            conn.Close()
+            return nil, fmt.Errorf("pragmaConnector: driver connection doesn't support ExecerContext, can't apply bootstrap pragma %q", pragma)
+        }
+
+        if _, err := execer.ExecContext(ctx, pragma, nil); err != nil {
+            conn.Close()
+            return nil, err
+        }
+    }
+
+    return conn, nil
+}
+
+// OpenDB opens a *sqlx.DB through connector, wrapped so activeDialect's
+// BootstrapPragmas are applied to every connection the pool ever opens, not
+// just whichever one happens to be free for a single db.Exec call. Callers
+// that need BootstrapPragmas to actually hold (e.g. SQLite's
+// foreign_keys=ON) should open their database through this rather than
+// sql.Open/sqlx.Open directly.
+func OpenDB(connector driver.Connector, driverName string) *sqlx.DB {
+    return sqlx.NewDb(sql.OpenDB(pragmaConnector{connector}), driverName)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+    "context"
+    "testing"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+    _ "github.com/mattn/go-sqlite3"
+)
+
+/* shared test fixtures */
+
+// openTestDB opens a throwaway in-memory sqlite database and brings it up
+// to the latest schema version via Bootstrap, for tests that need a real
+// Decks/DecksClosure schema to exercise against.
+func openTestDB(t *testing.T) *sqlx.DB {
+    t.Helper()
+
+    db, err := sqlx.Open("sqlite3", ":memory:")
+    if err != nil {
+        t.Fatalf("opening test db: %v", err)
+    }
+    t.Cleanup(func() { db.Close() })
+
+    if err := Bootstrap(db); err != nil {
+        t.Fatalf("bootstrapping test db: %v", err)
+    }
+
+    return db
+}
+
+// insertDeck inserts a deck named name and returns its id.
+func insertDeck(t *testing.T, db *sqlx.DB, name string) int64 {
+    t.Helper()
+
+    res, err := Exec(context.Background(), db, CREATE_NEW_DECK_QUERY, &StringMap{"name": name})
+    if err != nil {
+        t.Fatalf("inserting deck %q: %v", name, err)
+    }
+
+    id, err := res.LastInsertId()
+    if err != nil {
+        t.Fatalf("reading deck id for %q: %v", name, err)
+    }
+
+    return id
+}
+
+// linkChild associates childID as a direct child of parentID in the
+// closure table.
+func linkChild(t *testing.T, db *sqlx.DB, parentID int64, childID int64) {
+    t.Helper()
+
+    if _, err := Exec(context.Background(), db, ASSOCIATE_DECK_AS_CHILD_QUERY, &StringMap{"parent": parentID, "child": childID}); err != nil {
+        t.Fatalf("associating deck %d under %d: %v", childID, parentID, err)
+    }
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+    "errors"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* deck reparenting */
+
+// checks whether deck is an ancestor of candidate (candidate is in deck's
+// subtree), i.e. whether moving deck under candidate would create a cycle
+var DECK_IS_ANCESTOR_QUERY = (func() PipeInput {
+    const __DECK_IS_ANCESTOR_QUERY string = `
+    SELECT 1 FROM DecksClosure WHERE ancestor = :deck AND descendent = :candidate;
+    `
+
+    var requiredInputCols []string = []string{"deck", "candidate"}
+
+    return composePipes(
+        MakeCtxMaker(__DECK_IS_ANCESTOR_QUERY),
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// severs deck's ancestor links above it, leaving its own subtree intact;
+// this promotes deck (and everything under it) to a root
+var DETACH_DECK_QUERY = (func() PipeInput {
+    const __DETACH_DECK_QUERY string = `
+    DELETE FROM DecksClosure
+    WHERE descendent IN (SELECT descendent FROM DecksClosure WHERE ancestor = :deck)
+    AND ancestor NOT IN (SELECT descendent FROM DecksClosure WHERE ancestor = :deck);
+    `
+
+    var requiredInputCols []string = []string{"deck"}
+
+    return composePipes(
+        MakeCtxMaker(__DETACH_DECK_QUERY),
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// re-attaches deck's (detached) subtree under new_parent
+var MOVE_DECK_SUBTREE_QUERY = (func() PipeInput {
+    const __MOVE_DECK_SUBTREE_QUERY string = `
+    INSERT INTO DecksClosure(ancestor, descendent, depth)
+    SELECT sup.ancestor, sub.descendent, sup.depth + sub.depth + 1
+    FROM DecksClosure AS sup
+    CROSS JOIN DecksClosure AS sub
+    WHERE sup.descendent = :new_parent
+    AND sub.ancestor = :deck;
+    `
+
+    var requiredInputCols []string = []string{"deck", "new_parent"}
+
+    return composePipes(
+        MakeCtxMaker(__MOVE_DECK_SUBTREE_QUERY),
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// fetch every ancestor of a deck, ordered by ancestor for deterministic
+// paging; the closure-table inverse of DECK_CHILDREN_QUERY
+var DECK_ANCESTORS_QUERY = (func() PipeInput {
+    const __DECK_ANCESTORS_QUERY string = `
+    SELECT ancestor, descendent, depth
+    FROM DecksClosure
+    WHERE descendent = :deck
+    AND depth > 0
+    ORDER BY ancestor;
+    `
+
+    var requiredInputCols []string = []string{"deck"}
+
+    return composePipes(
+        MakeCtxMaker(__DECK_ANCESTORS_QUERY),
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// fetch every descendent of a deck, optionally capped at max_depth (0
+// means unlimited), ordered by descendent for deterministic paging
+var DECK_DESCENDENTS_QUERY = (func() PipeInput {
+    const __DECK_DESCENDENTS_QUERY string = `
+    SELECT ancestor, descendent, depth
+    FROM DecksClosure
+    WHERE ancestor = :deck
+    AND depth > 0
+    AND (:max_depth = 0 OR depth <= :max_depth)
+    ORDER BY descendent;
+    `
+
+    var requiredInputCols []string = []string{"deck", "max_depth"}
+
+    return composePipes(
+        MakeCtxMaker(__DECK_DESCENDENTS_QUERY),
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// ErrDeckMoveCycle is returned by MoveDeck when newParentID is itself a
+// descendent of deckID, which would otherwise corrupt the closure table.
+var ErrDeckMoveCycle = errors.New("deck move: new parent is a descendent of deck")
+
+// ErrDeckNotFound is returned by MoveDeck when deckID or newParentID
+// doesn't exist.
+var ErrDeckNotFound = errors.New("deck move: deck does not exist")
+
+// execInTx runs pipe for its side effect against tx, going through
+// QueryApplyContext/stmt.Tx the same way repository.go's Exec does against
+// db, so a cached prepared statement (once adopted onto tx) is reused
+// instead of rebuilding the query on every call.
+func execInTx(ctx context.Context, tx *sqlx.Tx, pipe PipeInput, in *StringMap) error {
+    query, args, stmt, err := QueryApplyContext(ctx, pipe, in)
+    if err != nil {
+        return err
+    }
+
+    if stmt != nil {
+        _, err = stmt.Tx(tx).ExecContext(ctx, *in)
+        return err
+    }
+
+    _, err = tx.ExecContext(ctx, query, args...)
+    return err
+}
+
+// deckIsAncestor reports whether deckID is an ancestor of candidateID (or
+// candidateID itself, since DecksClosure's self row makes every deck its own
+// depth-0 ancestor), per DECK_IS_ANCESTOR_QUERY.
+func deckIsAncestor(ctx context.Context, tx *sqlx.Tx, deckID int64, candidateID int64) (bool, error) {
+    in := &StringMap{"deck": deckID, "candidate": candidateID}
+
+    query, args, stmt, err := QueryApplyContext(ctx, DECK_IS_ANCESTOR_QUERY, in)
+    if err != nil {
+        return false, err
+    }
+
+    var exists int
+    if stmt != nil {
+        err = stmt.Tx(tx).GetContext(ctx, &exists, *in)
+    } else {
+        err = tx.GetContext(ctx, &exists, query, args...)
+    }
+
+    switch err {
+    case nil:
+        return true, nil
+    case sql.ErrNoRows:
+        return false, nil
+    default:
+        return false, err
+    }
+}
+
+// deckExists reports whether id has a depth-0 self row in DecksClosure,
+// which the decks_closure_new_deck trigger inserts for every deck on
+// creation, so its presence doubles as an existence check.
+func deckExists(ctx context.Context, tx *sqlx.Tx, id int64) (bool, error) {
+    return deckIsAncestor(ctx, tx, id, id)
+}
+
+// MoveDeck moves deckID (and its whole subtree) to become a child of
+// newParentID, via the standard closure-table move: reject the move if
+// deckID or newParentID doesn't exist, or if newParentID is a descendent of
+// deckID (that would be a cycle); otherwise sever deckID's existing
+// ancestor links above it, then reinsert deckID's subtree under
+// newParentID. The whole operation runs in one transaction.
+func MoveDeck(ctx context.Context, db *sqlx.DB, deckID int64, newParentID int64) error {
+
+    tx, err := db.BeginTxx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    switch exists, err := deckExists(ctx, tx, deckID); {
+    case err != nil:
+        return err
+    case !exists:
+        return ErrDeckNotFound
+    }
+
+    switch exists, err := deckExists(ctx, tx, newParentID); {
+    case err != nil:
+        return err
+    case !exists:
+        return ErrDeckNotFound
+    }
+
+    switch isAncestor, err := deckIsAncestor(ctx, tx, deckID, newParentID); {
+    case err != nil:
+        return err
+    case isAncestor:
+        return ErrDeckMoveCycle
+    }
+
+    if err := execInTx(ctx, tx, DETACH_DECK_QUERY, &StringMap{"deck": deckID}); err != nil {
+        return err
+    }
+
+    if err := execInTx(ctx, tx, MOVE_DECK_SUBTREE_QUERY, &StringMap{"deck": deckID, "new_parent": newParentID}); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// DetachDeck promotes deckID (and its whole subtree) to a root, severing
+// its ancestor links above it via DETACH_DECK_QUERY. It's MoveDeck's
+// severing step made standalone, for callers that want a subtree pulled
+// out to the top level rather than reattached elsewhere; it runs the same
+// validate-then-exec pattern in its own transaction.
+func DetachDeck(ctx context.Context, db *sqlx.DB, deckID int64) error {
+
+    tx, err := db.BeginTxx(ctx, nil)
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    switch exists, err := deckExists(ctx, tx, deckID); {
+    case err != nil:
+        return err
+    case !exists:
+        return ErrDeckNotFound
+    }
+
+    if err := execInTx(ctx, tx, DETACH_DECK_QUERY, &StringMap{"deck": deckID}); err != nil {
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// DeckAncestors returns every ancestor of deck id.
+func DeckAncestors(ctx context.Context, db *sqlx.DB, id int64) ([]DeckClosureEdge, error) {
+    return QueryAll[DeckClosureEdge](ctx, db, DECK_ANCESTORS_QUERY, &StringMap{"deck": id})
+}
+
+// DeckDescendents returns every descendent of deck id, capped at maxDepth
+// levels below it (0 means unlimited).
+func DeckDescendents(ctx context.Context, db *sqlx.DB, id int64, maxDepth int64) ([]DeckClosureEdge, error) {
+    return QueryAll[DeckClosureEdge](ctx, db, DECK_DESCENDENTS_QUERY, &StringMap{"deck": id, "max_depth": maxDepth})
+}
+
+// DeckAncestorsIter is DeckAncestors for callers walking a deep tree who
+// don't want every ancestor materialized up front, e.g. export or
+// bulk-scheduling over a deeply nested deck's whole lineage. batchSize pages the
+// scan LIMIT/OFFSET-style rather than holding one cursor open over the
+// whole result; 0 fetches the result set as a single page.
+func DeckAncestorsIter(ctx context.Context, db *sqlx.DB, id int64, batchSize int) (*RowIterator, error) {
+    return IterateBatched(ctx, db, DECK_ANCESTORS_QUERY, batchSize, &StringMap{"deck": id})
+}
+
+// DeckDescendentsIter is DeckDescendents for callers walking a deep tree
+// who don't want every descendent materialized up front, e.g. export or
+// bulk-scheduling over a root deck's whole subtree. batchSize pages the
+// scan LIMIT/OFFSET-style rather than holding one cursor open over the
+// whole result; 0 fetches the result set as a single page.
+func DeckDescendentsIter(ctx context.Context, db *sqlx.DB, id int64, maxDepth int64, batchSize int) (*RowIterator, error) {
+    return IterateBatched(ctx, db, DECK_DESCENDENTS_QUERY, batchSize, &StringMap{"deck": id, "max_depth": maxDepth})
+}
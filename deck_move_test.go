@@ -0,0 +1,89 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "testing"
+)
+
+func TestMoveDeckRejectsCycle(t *testing.T) {
+    db := openTestDB(t)
+    ctx := context.Background()
+
+    root := insertDeck(t, db, "root")
+    child := insertDeck(t, db, "child")
+    linkChild(t, db, root, child)
+
+    if err := MoveDeck(ctx, db, root, child); !errors.Is(err, ErrDeckMoveCycle) {
+        t.Fatalf("expected ErrDeckMoveCycle moving an ancestor under its own descendent, got %v", err)
+    }
+}
+
+func TestMoveDeckRejectsMissingDeck(t *testing.T) {
+    db := openTestDB(t)
+    ctx := context.Background()
+
+    root := insertDeck(t, db, "root")
+    const missingID int64 = 999999
+
+    if err := MoveDeck(ctx, db, missingID, root); !errors.Is(err, ErrDeckNotFound) {
+        t.Fatalf("expected ErrDeckNotFound for a missing deckID, got %v", err)
+    }
+
+    if err := MoveDeck(ctx, db, root, missingID); !errors.Is(err, ErrDeckNotFound) {
+        t.Fatalf("expected ErrDeckNotFound for a missing newParentID, got %v", err)
+    }
+}
+
+func TestMoveDeckReparents(t *testing.T) {
+    db := openTestDB(t)
+    ctx := context.Background()
+
+    oldParent := insertDeck(t, db, "old-parent")
+    newParent := insertDeck(t, db, "new-parent")
+    deck := insertDeck(t, db, "deck")
+    grandchild := insertDeck(t, db, "grandchild")
+
+    linkChild(t, db, oldParent, deck)
+    linkChild(t, db, deck, grandchild)
+
+    if err := MoveDeck(ctx, db, deck, newParent); err != nil {
+        t.Fatalf("MoveDeck: %v", err)
+    }
+
+    ancestors, err := DeckAncestors(ctx, db, deck)
+    if err != nil {
+        t.Fatalf("DeckAncestors: %v", err)
+    }
+
+    if len(ancestors) != 1 || ancestors[0].Ancestor != newParent {
+        t.Fatalf("expected deck's only ancestor to be newParent (%d), got %v", newParent, ancestors)
+    }
+
+    descendents, err := DeckDescendents(ctx, db, newParent, 0)
+    if err != nil {
+        t.Fatalf("DeckDescendents(newParent): %v", err)
+    }
+
+    depthOf := map[int64]int64{}
+    for _, edge := range descendents {
+        depthOf[edge.Descendent] = edge.Depth
+    }
+
+    if depth, ok := depthOf[deck]; !ok || depth != 1 {
+        t.Fatalf("expected deck at depth 1 under newParent, got %v", depthOf)
+    }
+
+    if depth, ok := depthOf[grandchild]; !ok || depth != 2 {
+        t.Fatalf("expected grandchild at depth 2 under newParent, got %v", depthOf)
+    }
+
+    oldDescendents, err := DeckDescendents(ctx, db, oldParent, 0)
+    if err != nil {
+        t.Fatalf("DeckDescendents(oldParent): %v", err)
+    }
+
+    if len(oldDescendents) != 0 {
+        t.Fatalf("expected oldParent to have no descendents after the move, got %v", oldDescendents)
+    }
+}
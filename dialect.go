@@ -0,0 +1,161 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* dialect adapter */
+
+// Dialect abstracts the handful of SQL idioms that differ between drivers,
+// so the query pipeline isn't hardcoded to the sqlite idioms (INSERT OR
+// REPLACE, PRAGMA foreign_keys, autoincrement syntax) this package grew up
+// with.
+type Dialect interface {
+    // Upsert returns an INSERT statement that overwrites on conflict with
+    // keys, setting every column in values. An empty keys is a plain
+    // insert with no conflict clause.
+    Upsert(table string, keys []string, values []string) string
+    // BootstrapPragmas returns any statements that must run once per
+    // connection before normal use.
+    BootstrapPragmas() []string
+    // DisableForeignKeys and EnableForeignKeys bracket a schema migration
+    // that touches FK-constrained tables, run on the same connection as
+    // the migration's transaction. Either may return "" for a dialect
+    // that has no equivalent blanket toggle, in which case the migration
+    // runner skips it.
+    DisableForeignKeys() string
+    EnableForeignKeys() string
+    // Now returns the SQL expression for the current timestamp.
+    Now() string
+    // BindType is the sqlx bindvar style this dialect's driver expects.
+    BindType() int
+    // AutoIncrementColumn returns the column definition for an
+    // auto-incrementing primary key named name.
+    AutoIncrementColumn(name string) string
+}
+
+func namedPlaceholders(cols []string) []string {
+    placeholders := make([]string, len(cols))
+    for i, col := range cols {
+        placeholders[i] = ":" + col
+    }
+
+    return placeholders
+}
+
+/* sqlite */
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Upsert(table string, keys []string, values []string) string {
+    cols := append(append([]string{}, keys...), values...)
+
+    verb := "INSERT OR REPLACE INTO"
+    if len(keys) == 0 {
+        verb = "INSERT INTO"
+    }
+
+    return fmt.Sprintf(
+        "%s %s(%s) VALUES (%s)",
+        verb, table, strings.Join(cols, ", "), strings.Join(namedPlaceholders(cols), ", "),
+    )
+}
+
+func (sqliteDialect) BootstrapPragmas() []string {
+    // re. foreign_keys:
+    // > Foreign key constraints are disabled by default (for backwards
+    // > compatibility), so must be enabled separately for each database
+    // > connection.
+    return []string{"PRAGMA foreign_keys=ON;"}
+}
+
+func (sqliteDialect) DisableForeignKeys() string {
+    return "PRAGMA foreign_keys=OFF;"
+}
+
+func (sqliteDialect) EnableForeignKeys() string {
+    return "PRAGMA foreign_keys=ON;"
+}
+
+func (sqliteDialect) Now() string {
+    return "datetime('now')"
+}
+
+func (sqliteDialect) BindType() int {
+    return sqlx.QUESTION
+}
+
+func (sqliteDialect) AutoIncrementColumn(name string) string {
+    return fmt.Sprintf("%s INTEGER PRIMARY KEY NOT NULL", name)
+}
+
+/* postgres */
+
+type postgresDialect struct{}
+
+func (postgresDialect) Upsert(table string, keys []string, values []string) string {
+    cols := append(append([]string{}, keys...), values...)
+
+    insert := fmt.Sprintf(
+        "INSERT INTO %s(%s) VALUES (%s)",
+        table, strings.Join(cols, ", "), strings.Join(namedPlaceholders(cols), ", "),
+    )
+
+    if len(keys) == 0 {
+        return insert
+    }
+
+    setStrings := make([]string, len(values))
+    for i, col := range values {
+        setStrings[i] = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+    }
+
+    return fmt.Sprintf(
+        "%s ON CONFLICT (%s) DO UPDATE SET %s",
+        insert, strings.Join(keys, ", "), strings.Join(setStrings, ", "),
+    )
+}
+
+func (postgresDialect) BootstrapPragmas() []string {
+    return nil
+}
+
+func (postgresDialect) DisableForeignKeys() string {
+    // Postgres has no blanket "ignore every FK" toggle equivalent to
+    // SQLite's pragma; the equivalent (deferring constraints, or altering
+    // each table) is left for when a migration actually needs it.
+    return ""
+}
+
+func (postgresDialect) EnableForeignKeys() string {
+    return ""
+}
+
+func (postgresDialect) Now() string {
+    return "now()"
+}
+
+func (postgresDialect) BindType() int {
+    return sqlx.DOLLAR
+}
+
+func (postgresDialect) AutoIncrementColumn(name string) string {
+    return fmt.Sprintf("%s SERIAL PRIMARY KEY", name)
+}
+
+/* active dialect */
+
+// activeDialect is the Dialect used to generate dialect-generated queries
+// and to rebind every built query in BuildQueryPipe. Defaults to sqlite,
+// matching this package's original single-driver assumption.
+var activeDialect Dialect = sqliteDialect{}
+
+// UseDialect switches the dialect used by dialect-generated queries and by
+// BuildQueryPipe's rebinding step. Call it before Bootstrap/Prepare.
+func UseDialect(d Dialect) {
+    activeDialect = d
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* streaming iteration over large result sets */
+
+// RowIterator walks the rows produced by a pipe lazily, rather than
+// materializing the full result set the way QueryApply-backed callers do.
+// This matters for the closure-table queries (e.g. fetching every
+// descendent of a root deck for export or bulk-scheduling), where loading
+// the whole set up front doesn't scale.
+type RowIterator struct {
+    ctx       context.Context
+    db        *sqlx.DB
+    pipe      PipeInput
+    in        []*StringMap
+    batchSize int
+    offset    int
+    pageRows  int
+    rows      *sqlx.Rows
+    err       error
+    done      bool
+}
+
+// Iterate returns a RowIterator over the rows pipe produces, read one at a
+// time via QueryxContext rather than loaded into a slice. Nothing runs
+// until the first Next: the pipe is applied and the rows are opened there,
+// so an error from either is reported by Next/Err, not returned here
+// (Iterate's own error is always nil). ctx is threaded through every page fetch, so
+// cancelling it stops a long scan rather than running it to completion.
+func Iterate(ctx context.Context, db *sqlx.DB, pipe PipeInput, in ...*StringMap) (*RowIterator, error) {
+    return &RowIterator{ctx: ctx, db: db, pipe: pipe, in: in}, nil
+}
+
+// IterateBatched is Iterate with paging: instead of holding a single cursor
+// open over the whole result set, it pages through with LIMIT/OFFSET,
+// batchSize rows at a time, so long enumerations can be resumed cheaply
+// rather than holding one connection for the duration.
+func IterateBatched(ctx context.Context, db *sqlx.DB, pipe PipeInput, batchSize int, in ...*StringMap) (*RowIterator, error) {
+    it, err := Iterate(ctx, db, pipe, in...)
+    if err != nil {
+        return nil, err
+    }
+
+    it.batchSize = batchSize
+    return it, nil
+}
+
+// Next advances the iterator, fetching the next page once the current one
+// is exhausted. It returns false when there are no more rows, ctx is
+// cancelled, or an error occurred; callers should check Err afterwards.
+func (it *RowIterator) Next() bool {
+    if it.err != nil || it.done {
+        return false
+    }
+
+    if err := it.ctx.Err(); err != nil {
+        it.err = err
+        return false
+    }
+
+    for {
+        if it.rows == nil {
+            if err := it.fetchPage(); err != nil {
+                it.err = err
+                return false
+            }
+
+            if it.rows == nil {
+                it.done = true
+                return false
+            }
+        }
+
+        if it.rows.Next() {
+            it.pageRows++
+            return true
+        }
+
+        if err := it.rows.Err(); err != nil {
+            it.err = err
+            return false
+        }
+
+        it.rows.Close()
+        it.rows = nil
+
+        // no paging configured: a single page is the whole result set
+        if it.batchSize <= 0 {
+            it.done = true
+            return false
+        }
+
+        // a page shorter than batchSize (an empty one included) means
+        // there's nothing left to page in; without this, the empty page
+        // fetched past the end of the result set would be followed by
+        // another, looping forever instead of stopping. A last page that
+        // lands exactly on the end is full, so the scan takes one extra
+        // (empty) page to stop
+        if it.pageRows < it.batchSize {
+            it.done = true
+            return false
+        }
+    }
+}
+
+func (it *RowIterator) fetchPage() error {
+
+    it.pageRows = 0
+
+    // a cached prepared statement has no LIMIT/OFFSET baked in, so it's
+    // only usable for the unpaged, single-page case; a paged scan always
+    // rebuilds query/args fresh via QueryApply below instead, since
+    // QueryApplyContext's query/args go stale (BuildQueryPipe short-circuits
+    // them) once a stmt exists for this template.
+    if it.batchSize <= 0 {
+        _, _, stmt, err := QueryApplyContext(it.ctx, it.pipe, it.in...)
+        if err != nil {
+            return err
+        }
+
+        if stmt != nil {
+            rows, err := stmt.QueryxContext(it.ctx, mergeStringMaps(it.in))
+            if err != nil {
+                return err
+            }
+
+            it.rows = rows
+            return nil
+        }
+    }
+
+    query, args, err := QueryApply(it.pipe, it.in...)
+    if err != nil {
+        return err
+    }
+
+    if it.batchSize > 0 {
+        query = fmt.Sprintf("%s LIMIT %d OFFSET %d", strings.TrimSuffix(strings.TrimSpace(query), ";"), it.batchSize, it.offset)
+        it.offset += it.batchSize
+    }
+
+    rows, err := it.db.QueryxContext(it.ctx, query, args...)
+    if err != nil {
+        return err
+    }
+
+    it.rows = rows
+    return nil
+}
+
+// Scan copies the current row into dest, per sqlx.Rows.StructScan.
+func (it *RowIterator) Scan(dest interface{}) error {
+    if it.rows == nil {
+        return errors.New("RowIterator: Scan called before a successful Next")
+    }
+
+    return it.rows.StructScan(dest)
+}
+
+// Err returns the first error encountered while iterating, if any.
+func (it *RowIterator) Err() error {
+    return it.err
+}
+
+// Close releases the iterator's current page, if one is open.
+func (it *RowIterator) Close() error {
+    if it.rows == nil {
+        return nil
+    }
+
+    return it.rows.Close()
+}
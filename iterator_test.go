@@ -0,0 +1,42 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "testing"
+)
+
+// TestRowIteratorStopsOnExactBatchBoundary covers the page-boundary case
+// where the total row count is an exact multiple of batchSize: the last
+// full page looks the same as a mid-scan page (pageRows == batchSize), so
+// Next must fetch one more (empty) page to recognize the scan is over
+// rather than looping forever.
+func TestRowIteratorStopsOnExactBatchBoundary(t *testing.T) {
+    db := openTestDB(t)
+    ctx := context.Background()
+
+    parent := insertDeck(t, db, "parent")
+    for i := 0; i < 4; i++ {
+        child := insertDeck(t, db, fmt.Sprintf("child-%d", i))
+        linkChild(t, db, parent, child)
+    }
+
+    it, err := IterateBatched(ctx, db, DECK_CHILDREN_QUERY, 2, &StringMap{"parent": parent})
+    if err != nil {
+        t.Fatalf("IterateBatched: %v", err)
+    }
+    defer it.Close()
+
+    var rows int
+    for it.Next() {
+        rows++
+    }
+
+    if err := it.Err(); err != nil {
+        t.Fatalf("iterating: %v", err)
+    }
+
+    if rows != 4 {
+        t.Fatalf("expected exactly 4 rows across two full batchSize-2 pages, got %d", rows)
+    }
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+    "context"
+    "fmt"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* schema migrations */
+
+// Migration is a single versioned schema change. Up brings the schema from
+// Version-1 to Version; Down reverses it. Up runs inside the transaction
+// runMigration opens for that migration when Bootstrap applies it; nothing
+// in this package calls Down.
+type Migration struct {
+    Version int
+    Name    string
+    Up      func(tx *sqlx.Tx) error
+    Down    func(tx *sqlx.Tx) error
+}
+
+const SETUP_SCHEMA_MIGRATIONS_TABLE_QUERY string = `
+CREATE TABLE IF NOT EXISTS SchemaMigrations (
+    version INTEGER PRIMARY KEY NOT NULL,
+    applied_at TEXT NOT NULL
+);
+`
+
+// input: version
+var INSERT_SCHEMA_MIGRATION_QUERY = (func() PipeInput {
+    var requiredInputCols []string = []string{"version"}
+
+    makeCtx := func() *QueryContext {
+        var ctx QueryContext
+        ctx.query = fmt.Sprintf(
+            "INSERT INTO SchemaMigrations(version, applied_at) VALUES (:version, %s);",
+            activeDialect.Now(),
+        )
+        ctx.nameArgs = &(StringMap{})
+        return &ctx
+    }
+
+    return composePipes(
+        makeCtx,
+        EnsureInputColsPipe(requiredInputCols),
+        BuildQueryPipe,
+    )
+}())
+
+// migrations is the ordered registry of every known schema version. New
+// columns/tables get their own migration appended here rather than editing
+// the DDL an already-deployed migration ran.
+var migrations []Migration = []Migration{
+    migration1,
+}
+
+// migration1 is the original bootstrap DDL (config table, decks table, and
+// the decks closure table + trigger) lifted as-is into the migration path.
+var migration1 Migration = Migration{
+    Version: 1,
+    Name:    "config and decks",
+    Up: func(tx *sqlx.Tx) error {
+        if _, err := tx.Exec(SETUP_CONFIG_TABLE_QUERY); err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(SETUP_DECKS_TABLE_QUERY()); err != nil {
+            return err
+        }
+
+        return nil
+    },
+    Down: func(tx *sqlx.Tx) error {
+        if _, err := tx.Exec(`DROP TABLE IF EXISTS DecksClosure;`); err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(`DROP TABLE IF EXISTS Decks;`); err != nil {
+            return err
+        }
+
+        if _, err := tx.Exec(`DROP TABLE IF EXISTS Config;`); err != nil {
+            return err
+        }
+
+        return nil
+    },
+}
+
+// appliedMigrationVersions returns the set of migration versions already
+// recorded in SchemaMigrations.
+func appliedMigrationVersions(db *sqlx.DB) (map[int]bool, error) {
+    var versions []int
+
+    err := db.Select(&versions, `SELECT version FROM SchemaMigrations ORDER BY version;`)
+    if err != nil {
+        return nil, err
+    }
+
+    applied := make(map[int]bool, len(versions))
+    for _, version := range versions {
+        applied[version] = true
+    }
+
+    return applied, nil
+}
+
+// runMigration applies a single pending migration inside its own
+// transaction. Per SQLite's recommended pattern for schema changes
+// involving foreign keys, FK enforcement is disabled around the migration
+// (via activeDialect.DisableForeignKeys/EnableForeignKeys, a no-op for
+// dialects with no blanket toggle) and restored once it's done, success or
+// failure. The pragma toggle and the migration's transaction are run on
+// the same pulled connection: a SQLite pragma is per-connection state, so
+// issuing it against the pool (db.Exec) and then beginning the transaction
+// (db.Beginx) separately risks the pool handing the transaction a
+// different physical connection than the one the pragma touched.
+func runMigration(db *sqlx.DB, migration Migration) error {
+
+    ctx := context.Background()
+
+    conn, err := db.Connx(ctx)
+    if err != nil {
+        return err
+    }
+    defer conn.Close()
+
+    if pragma := activeDialect.DisableForeignKeys(); pragma != "" {
+        if _, err := conn.ExecContext(ctx, pragma); err != nil {
+            return err
+        }
+    }
+    defer func() {
+        if pragma := activeDialect.EnableForeignKeys(); pragma != "" {
+            conn.ExecContext(ctx, pragma)
+        }
+    }()
+
+    tx, err := conn.BeginTxx(ctx, nil)
+    if err != nil {
+        return err
+    }
+
+    if err := migration.Up(tx); err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    in := &StringMap{"version": migration.Version}
+
+    query, args, stmt, err := QueryApplyContext(ctx, INSERT_SCHEMA_MIGRATION_QUERY, in)
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    if stmt != nil {
+        _, err = stmt.Tx(tx).ExecContext(ctx, *in)
+    } else {
+        _, err = tx.ExecContext(ctx, query, args...)
+    }
+    if err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// Bootstrap brings db up to the latest registered schema version, running
+// every pending migration in order. It does not itself apply
+// activeDialect's BootstrapPragmas (e.g. re-enabling foreign key
+// enforcement): those need to hold for every connection the pool ever
+// opens, not just whichever one a single db.Exec call happens to land on,
+// so they belong at connection-open time — see OpenDB. db must already
+// have been opened that way before calling Bootstrap.
+func Bootstrap(db *sqlx.DB) error {
+
+    if _, err := db.Exec(SETUP_SCHEMA_MIGRATIONS_TABLE_QUERY); err != nil {
+        return err
+    }
+
+    applied, err := appliedMigrationVersions(db)
+    if err != nil {
+        return err
+    }
+
+    for _, migration := range migrations {
+        if applied[migration.Version] {
+            continue
+        }
+
+        if err := runMigration(db, migration); err != nil {
+            return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestBootstrapAppliesMigration1(t *testing.T) {
+    db := openTestDB(t)
+
+    var versions []int
+    if err := db.Select(&versions, `SELECT version FROM SchemaMigrations ORDER BY version;`); err != nil {
+        t.Fatalf("querying SchemaMigrations: %v", err)
+    }
+
+    if len(versions) != 1 || versions[0] != 1 {
+        t.Fatalf("expected SchemaMigrations to contain exactly [1], got %v", versions)
+    }
+
+    // migration1's DDL should have left Decks usable
+    if _, err := db.Exec(`INSERT INTO Decks(name) VALUES ('root');`); err != nil {
+        t.Fatalf("inserting into Decks post-migration: %v", err)
+    }
+}
+
+func TestBootstrapIsIdempotent(t *testing.T) {
+    db := openTestDB(t)
+
+    if err := Bootstrap(db); err != nil {
+        t.Fatalf("second Bootstrap: %v", err)
+    }
+
+    var count int
+    if err := db.Get(&count, `SELECT COUNT(*) FROM SchemaMigrations;`); err != nil {
+        t.Fatalf("counting SchemaMigrations: %v", err)
+    }
+
+    if count != 1 {
+        t.Fatalf("expected migration 1 to be applied exactly once across two Bootstrap calls, got %d rows", count)
+    }
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "testing"
+)
+
+// TestWarmedCacheQueryApply covers the bug where BuildQueryPipe's
+// prepared-stmt short-circuit left QueryApply returning a stale,
+// unparsed query/args pair once Prepare(db) had cached that query's
+// template.
+func TestWarmedCacheQueryApply(t *testing.T) {
+    db := openTestDB(t)
+
+    if err := Prepare(db); err != nil {
+        t.Fatalf("Prepare: %v", err)
+    }
+
+    id := insertDeck(t, db, "warmed")
+
+    query, args, err := QueryApply(FETCH_DECK_QUERY, &StringMap{"deck_id": id})
+    if err != nil {
+        t.Fatalf("QueryApply: %v", err)
+    }
+
+    var deck Deck
+    if err := db.GetContext(context.Background(), &deck, query, args...); err != nil {
+        t.Fatalf("executing QueryApply's query/args against a warmed cache: %v", err)
+    }
+
+    if deck.ID != id || deck.Name != "warmed" {
+        t.Fatalf("expected deck {%d warmed}, got %+v", id, deck)
+    }
+}
+
+// TestWarmedCacheRowIteratorPaged covers the analogous bug in
+// RowIterator.fetchPage's paged branch.
+func TestWarmedCacheRowIteratorPaged(t *testing.T) {
+    db := openTestDB(t)
+
+    if err := Prepare(db); err != nil {
+        t.Fatalf("Prepare: %v", err)
+    }
+
+    ctx := context.Background()
+    parent := insertDeck(t, db, "parent")
+    for i := 0; i < 3; i++ {
+        child := insertDeck(t, db, fmt.Sprintf("child-%d", i))
+        linkChild(t, db, parent, child)
+    }
+
+    it, err := IterateBatched(ctx, db, DECK_CHILDREN_QUERY, 2, &StringMap{"parent": parent})
+    if err != nil {
+        t.Fatalf("IterateBatched: %v", err)
+    }
+    defer it.Close()
+
+    var rows int
+    for it.Next() {
+        rows++
+    }
+
+    if err := it.Err(); err != nil {
+        t.Fatalf("iterating a paged scan against a warmed cache: %v", err)
+    }
+
+    if rows != 3 {
+        t.Fatalf("expected 3 rows, got %d", rows)
+    }
+}
+
+// TestWarmedCacheMoveDeck covers the analogous bug in MoveDeck/DetachDeck's
+// execInTx/deckIsAncestor helpers.
+func TestWarmedCacheMoveDeck(t *testing.T) {
+    db := openTestDB(t)
+
+    if err := Prepare(db); err != nil {
+        t.Fatalf("Prepare: %v", err)
+    }
+
+    ctx := context.Background()
+    oldParent := insertDeck(t, db, "old-parent")
+    newParent := insertDeck(t, db, "new-parent")
+    deck := insertDeck(t, db, "deck")
+    linkChild(t, db, oldParent, deck)
+
+    if err := MoveDeck(ctx, db, deck, newParent); err != nil {
+        t.Fatalf("MoveDeck against a warmed cache: %v", err)
+    }
+
+    ancestors, err := DeckAncestors(ctx, db, deck)
+    if err != nil {
+        t.Fatalf("DeckAncestors: %v", err)
+    }
+
+    if len(ancestors) != 1 || ancestors[0].Ancestor != newParent {
+        t.Fatalf("expected deck's only ancestor to be newParent (%d), got %v", newParent, ancestors)
+    }
+}
@@ -1,24 +1,17 @@
 package main
 
 import (
+    "context"
     "encoding/json"
     "errors"
     "fmt"
     "strings"
+    "sync"
 
     // 3rd-party
     "github.com/jmoiron/sqlx"
 )
 
-/* bootstrap */
-
-// re. foreign_keys:
-// > Foreign key constraints are disabled by default (for backwards compatibility),
-// > so must be enabled separately for each database connection.
-const BOOTSTRAP_QUERY string = `
-PRAGMA foreign_keys=ON;
-`
-
 /* config table */
 const SETUP_CONFIG_TABLE_QUERY string = `
 CREATE TABLE IF NOT EXISTS Config (
@@ -45,23 +38,34 @@ var FETCH_CONFIG_SETTING_QUERY = (func() PipeInput {
 
 // input: setting, value
 var SET_CONFIG_SETTING_QUERY = (func() PipeInput {
-    const __INSERT_CONFIG_SETTING_QUERY string = `
-    INSERT OR REPLACE INTO Config(setting, value) VALUES (:setting, :value);
-    `
-
     var requiredInputCols []string = []string{"setting", "value"}
 
+    makeCtx := func() *QueryContext {
+        var ctx QueryContext
+        ctx.query = activeDialect.Upsert("Config", []string{"setting"}, []string{"value"})
+        ctx.nameArgs = &(StringMap{})
+        return &ctx
+    }
+
     return composePipes(
-        MakeCtxMaker(__INSERT_CONFIG_SETTING_QUERY),
+        makeCtx,
         EnsureInputColsPipe(requiredInputCols),
         BuildQueryPipe,
     )
 }())
 
 /* decks table */
-const SETUP_DECKS_TABLE_QUERY string = `
+
+// SETUP_DECKS_TABLE_QUERY builds the Decks/DecksClosure DDL, with deck_id's
+// column definition generated from activeDialect.AutoIncrementColumn. The
+// closure-table trigger below is SQLite's BEGIN...END trigger-body syntax;
+// a dialect whose driver doesn't support it (e.g. Postgres, which needs a
+// trigger function) can't run this migration as-is — that part of the DDL
+// isn't dialect-generated yet.
+func SETUP_DECKS_TABLE_QUERY() string {
+    return fmt.Sprintf(`
 CREATE TABLE IF NOT EXISTS Decks (
-    deck_id INTEGER PRIMARY KEY NOT NULL,
+    %s,
     name TEXT NOT NULL,
     CHECK (name <> '') /* ensure not empty */
 );
@@ -82,16 +86,21 @@ ON Decks
 BEGIN
     INSERT OR IGNORE INTO DecksClosure(ancestor, descendent, depth) VALUES (NEW.deck_id, NEW.deck_id, 0);
 END;
-`
+`, activeDialect.AutoIncrementColumn("deck_id"))
+}
 
 var CREATE_NEW_DECK_QUERY = (func() PipeInput {
-    const __CREATE_NEW_DECK_QUERY string = `
-    INSERT INTO Decks(name) VALUES (:name);
-    `
     var requiredInputCols []string = []string{"name"}
 
+    makeCtx := func() *QueryContext {
+        var ctx QueryContext
+        ctx.query = activeDialect.Upsert("Decks", nil, []string{"name"})
+        ctx.nameArgs = &(StringMap{})
+        return &ctx
+    }
+
     return composePipes(
-        MakeCtxMaker(__CREATE_NEW_DECK_QUERY),
+        makeCtx,
         EnsureInputColsPipe(requiredInputCols),
         BuildQueryPipe,
     )
@@ -166,7 +175,8 @@ var DECK_CHILDREN_QUERY = (func() PipeInput {
     FROM DecksClosure
     WHERE
     ancestor = :parent
-    AND depth = 1;
+    AND depth = 1
+    ORDER BY descendent;
     `
 
     var requiredInputCols []string = []string{"parent"}
@@ -198,26 +208,39 @@ type QueryContext struct {
     query    string
     nameArgs *StringMap
     args     []interface{}
+
+    // template is the stable address of this pipe's base query string, as
+    // registered with Prepare; used to key the prepared-statement cache.
+    template *string
 }
 
 func MakeCtxMaker(baseQuery string) func() *QueryContext {
+    query := baseQuery
+    registerQueryTemplate(&query)
+
     return func() *QueryContext {
         var ctx QueryContext
-        ctx.query = baseQuery
+        ctx.query = query
+        ctx.template = &query
         ctx.nameArgs = &(StringMap{})
 
         return &ctx
     }
 }
 
-type PipeInput func(...interface{}) (*QueryContext, PipeInput, error)
+// PipeInput takes the context.Context a caller threaded through via
+// QueryApply/QueryApplyContext, so it reaches every pipe stage down to
+// BuildQueryPipe, which only reads the stmtCacheKey marker from it (nothing
+// in the pipeline itself makes a blocking call); QueryApplyContext's ctx is what actually
+// matters, since it's what the caller executes the built query under.
+type PipeInput func(context.Context, ...interface{}) (*QueryContext, PipeInput, error)
 type Pipe func(*QueryContext, *([]Pipe)) PipeInput
 
 // TODO: rename to waterfallPipes; since this isn't really an actual compose operation
 func composePipes(makeCtx func() *QueryContext, pipes ...Pipe) PipeInput {
 
     if len(pipes) <= 0 {
-        return func(args ...interface{}) (*QueryContext, PipeInput, error) {
+        return func(reqCtx context.Context, args ...interface{}) (*QueryContext, PipeInput, error) {
             return nil, nil, nil
             // noop
         }
@@ -225,14 +248,14 @@ func composePipes(makeCtx func() *QueryContext, pipes ...Pipe) PipeInput {
 
     var firstPipe Pipe = pipes[0]
     var restPipes []Pipe = pipes[1:]
-    return func(args ...interface{}) (*QueryContext, PipeInput, error) {
-        return firstPipe(makeCtx(), &restPipes)(args...)
+    return func(reqCtx context.Context, args ...interface{}) (*QueryContext, PipeInput, error) {
+        return firstPipe(makeCtx(), &restPipes)(reqCtx, args...)
     }
 }
 
 func EnsureInputColsPipe(required []string) Pipe {
     return func(ctx *QueryContext, pipes *([]Pipe)) PipeInput {
-        return func(args ...interface{}) (*QueryContext, PipeInput, error) {
+        return func(reqCtx context.Context, args ...interface{}) (*QueryContext, PipeInput, error) {
 
             var (
                 inputMap *StringMap = args[0].(*StringMap)
@@ -261,7 +284,7 @@ func EnsureInputColsPipe(required []string) Pipe {
 // for updating value of cols
 func PatchFilterPipe(whitelist []string) Pipe {
     return func(ctx *QueryContext, pipes *([]Pipe)) PipeInput {
-        return func(args ...interface{}) (*QueryContext, PipeInput, error) {
+        return func(reqCtx context.Context, args ...interface{}) (*QueryContext, PipeInput, error) {
 
             var (
                 patch           *StringMap = args[0].(*StringMap)
@@ -296,27 +319,45 @@ func PatchFilterPipe(whitelist []string) Pipe {
     }
 }
 
+// stmtCacheKey marks a reqCtx as coming from QueryApplyContext, whose
+// callers get the prepared stmt back and execute against it directly, so
+// BuildQueryPipe can safely skip building ctx.query/ctx.args for them. It
+// must not be set for QueryApply's reqCtx: that API only ever returns
+// ctx.query/ctx.args to its caller, with no way to hand back a stmt, so it
+// always needs those populated regardless of what's cached.
+type stmtCacheKey struct{}
+
 func BuildQueryPipe(ctx *QueryContext, _ *([]Pipe)) PipeInput {
-    return func(args ...interface{}) (*QueryContext, PipeInput, error) {
+    return func(reqCtx context.Context, args ...interface{}) (*QueryContext, PipeInput, error) {
+
+        // a prepared statement already covers this query's template: skip
+        // re-parsing ctx.query through sqlx.Named/Rebind, since the caller
+        // (QueryApplyContext) gets the stmt back and executes against it
+        // directly instead of using ctx.query/ctx.args.
+        if reqCtx.Value(stmtCacheKey{}) != nil {
+            if _, ok := lookupPreparedStmt(ctx.template); ok {
+                return ctx, nil, nil
+            }
+        }
 
         // this apparently doesn't work
         // var nameArgs StringMap = *((*ctx).nameArgs)
         var nameArgs map[string]interface{} = *((*ctx).nameArgs)
 
-        query, args, err := sqlx.Named((*ctx).query, nameArgs)
+        query, queryArgs, err := sqlx.Named((*ctx).query, nameArgs)
 
         if err != nil {
             return nil, nil, err
         }
 
-        ctx.query = query
-        ctx.args = args
+        ctx.query = sqlx.Rebind(activeDialect.BindType(), query)
+        ctx.args = queryArgs
 
         return ctx, nil, nil
     }
 }
 
-func QueryApply(pipe PipeInput, stringmaps ...*StringMap) (string, []interface{}, error) {
+func runPipe(reqCtx context.Context, pipe PipeInput, stringmaps ...*StringMap) (*QueryContext, error) {
 
     var (
         err         error
@@ -334,15 +375,108 @@ func QueryApply(pipe PipeInput, stringmaps ...*StringMap) (string, []interface{}
             idx++
         }
 
-        ctx, currentPipe, err = currentPipe(args...)
+        ctx, currentPipe, err = currentPipe(reqCtx, args...)
         if err != nil {
-            return "", nil, err
+            return nil, err
         }
     }
 
+    return ctx, nil
+}
+
+func QueryApply(pipe PipeInput, stringmaps ...*StringMap) (string, []interface{}, error) {
+
+    ctx, err := runPipe(context.Background(), pipe, stringmaps...)
+    if err != nil {
+        return "", nil, err
+    }
+
     if ctx != nil {
         return ctx.query, ctx.args, nil
     }
 
     return "", nil, nil
 }
+
+// QueryApplyContext is QueryApply with ctx threaded through every pipe
+// stage down to BuildQueryPipe, so callers that execute the built query
+// themselves (e.g. RowIterator, which keeps its own context alongside the
+// query/args this returns) can do so under the same ctx. It also surfaces a
+// prepared statement from Prepare's warmup, if this pipe's base query was
+// registered and isn't a PatchFilterPipe query (whose final text varies per
+// call and so can't be prepared ahead of time): callers that get one back
+// can execute against it directly instead of re-preparing on every call.
+func QueryApplyContext(ctx context.Context, pipe PipeInput, stringmaps ...*StringMap) (string, []interface{}, *sqlx.NamedStmt, error) {
+
+    qctx, err := runPipe(context.WithValue(ctx, stmtCacheKey{}, true), pipe, stringmaps...)
+    if err != nil {
+        return "", nil, nil, err
+    }
+
+    if qctx == nil {
+        return "", nil, nil, nil
+    }
+
+    stmt, _ := lookupPreparedStmt(qctx.template)
+
+    return qctx.query, qctx.args, stmt, nil
+}
+
+/* prepared-statement cache */
+
+var (
+    queryTemplatesMu sync.Mutex
+    queryTemplates   []*string
+
+    preparedStmtsMu sync.RWMutex
+    preparedStmts   map[*string]*sqlx.NamedStmt = make(map[*string]*sqlx.NamedStmt)
+)
+
+func registerQueryTemplate(query *string) {
+    queryTemplatesMu.Lock()
+    defer queryTemplatesMu.Unlock()
+
+    queryTemplates = append(queryTemplates, query)
+}
+
+func lookupPreparedStmt(template *string) (*sqlx.NamedStmt, bool) {
+    if template == nil {
+        return nil, false
+    }
+
+    preparedStmtsMu.RLock()
+    defer preparedStmtsMu.RUnlock()
+
+    stmt, ok := preparedStmts[template]
+    return stmt, ok
+}
+
+// Prepare walks every registered query (FETCH_DECK_QUERY,
+// ASSOCIATE_DECK_AS_CHILD_QUERY, etc.) and prepares a named statement for
+// it against db, so the first real call reuses a warmed-up statement
+// instead of paying preparation cost inline. Queries built with a patch
+// placeholder (e.g. UPDATE_DECK_QUERY's "%s") are skipped, since their
+// final text depends on the columns patched at call time.
+func Prepare(db *sqlx.DB) error {
+
+    queryTemplatesMu.Lock()
+    templates := append([]*string(nil), queryTemplates...)
+    queryTemplatesMu.Unlock()
+
+    for _, template := range templates {
+        if strings.Contains(*template, "%s") {
+            continue
+        }
+
+        stmt, err := db.PrepareNamedContext(context.Background(), *template)
+        if err != nil {
+            return fmt.Errorf("preparing query %q: %w", *template, err)
+        }
+
+        preparedStmtsMu.Lock()
+        preparedStmts[template] = stmt
+        preparedStmtsMu.Unlock()
+    }
+
+    return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+    "context"
+    "database/sql"
+
+    // 3rd-party
+    "github.com/jmoiron/sqlx"
+)
+
+/* typed repositories over the raw pipe API */
+
+// Deck mirrors a single row of the Decks table.
+type Deck struct {
+    ID   int64  `db:"deck_id"`
+    Name string `db:"name"`
+}
+
+// DeckClosureEdge mirrors a single row of the DecksClosure table.
+type DeckClosureEdge struct {
+    Ancestor   int64 `db:"ancestor"`
+    Descendent int64 `db:"descendent"`
+    Depth      int64 `db:"depth"`
+}
+
+func mergeStringMaps(maps []*StringMap) StringMap {
+    merged := StringMap{}
+
+    for _, m := range maps {
+        if m == nil {
+            continue
+        }
+
+        for k, v := range *m {
+            merged[k] = v
+        }
+    }
+
+    return merged
+}
+
+// QueryOne runs pipe and scans a single row into a T. When Prepare warmed
+// up a statement for pipe's base query, it's used directly; otherwise this
+// falls back to a plain NamedContext-style query built via
+// QueryApplyContext.
+func QueryOne[T any](ctx context.Context, db *sqlx.DB, pipe PipeInput, in ...*StringMap) (T, error) {
+    var dest T
+
+    query, args, stmt, err := QueryApplyContext(ctx, pipe, in...)
+    if err != nil {
+        return dest, err
+    }
+
+    if stmt != nil {
+        err = stmt.GetContext(ctx, &dest, mergeStringMaps(in))
+        return dest, err
+    }
+
+    err = db.GetContext(ctx, &dest, query, args...)
+    return dest, err
+}
+
+// QueryAll is QueryOne for result sets of more than one row.
+func QueryAll[T any](ctx context.Context, db *sqlx.DB, pipe PipeInput, in ...*StringMap) ([]T, error) {
+    var dest []T
+
+    query, args, stmt, err := QueryApplyContext(ctx, pipe, in...)
+    if err != nil {
+        return nil, err
+    }
+
+    if stmt != nil {
+        err = stmt.SelectContext(ctx, &dest, mergeStringMaps(in))
+        return dest, err
+    }
+
+    err = db.SelectContext(ctx, &dest, query, args...)
+    return dest, err
+}
+
+// Exec runs pipe for its side effect (insert/update/delete) and returns the
+// driver's sql.Result.
+func Exec(ctx context.Context, db *sqlx.DB, pipe PipeInput, in ...*StringMap) (sql.Result, error) {
+    query, args, stmt, err := QueryApplyContext(ctx, pipe, in...)
+    if err != nil {
+        return nil, err
+    }
+
+    if stmt != nil {
+        return stmt.ExecContext(ctx, mergeStringMaps(in))
+    }
+
+    return db.ExecContext(ctx, query, args...)
+}
+
+// Repository is a thin typed wrapper over QueryOne/QueryAll/Exec for a
+// single table, so the per-table repositories below (DeckRepository, etc.)
+// don't each repeat the *StringMap/interface{} plumbing.
+type Repository[T any] struct {
+    db *sqlx.DB
+}
+
+func (r Repository[T]) one(ctx context.Context, pipe PipeInput, in ...*StringMap) (T, error) {
+    return QueryOne[T](ctx, r.db, pipe, in...)
+}
+
+func (r Repository[T]) all(ctx context.Context, pipe PipeInput, in ...*StringMap) ([]T, error) {
+    return QueryAll[T](ctx, r.db, pipe, in...)
+}
+
+func (r Repository[T]) exec(ctx context.Context, pipe PipeInput, in ...*StringMap) (sql.Result, error) {
+    return Exec(ctx, r.db, pipe, in...)
+}
+
+// DeckRepository is the typed front door onto the Decks / DecksClosure
+// pipes: callers pass plain Go values instead of building *StringMap args
+// and casting interface{} results by hand.
+type DeckRepository struct {
+    Repository[Deck]
+}
+
+func NewDeckRepository(db *sqlx.DB) DeckRepository {
+    return DeckRepository{Repository[Deck]{db: db}}
+}
+
+// Get fetches a single deck by id.
+func (r DeckRepository) Get(ctx context.Context, id int64) (Deck, error) {
+    return r.one(ctx, FETCH_DECK_QUERY, &StringMap{"deck_id": id})
+}
+
+// Children fetches the direct children of parentID.
+func (r DeckRepository) Children(ctx context.Context, parentID int64) ([]DeckClosureEdge, error) {
+    return QueryAll[DeckClosureEdge](ctx, r.db, DECK_CHILDREN_QUERY, &StringMap{"parent": parentID})
+}
+
+// Patch applies a partial update to deck id, per UPDATE_DECK_QUERY's
+// whitelisted columns.
+func (r DeckRepository) Patch(ctx context.Context, id int64, patch *StringMap) (sql.Result, error) {
+    return r.exec(ctx, UPDATE_DECK_QUERY, &StringMap{"deck_id": id}, patch)
+}
+
+// Decks is the package-wide deck repository. Callers must assign it via
+// Decks = NewDeckRepository(db) during setup, alongside Bootstrap and
+// Prepare, before using Decks.Get / Decks.Children / Decks.Patch.
+var Decks DeckRepository
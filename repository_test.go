@@ -0,0 +1,47 @@
+package main
+
+import (
+    "context"
+    "testing"
+)
+
+func TestDeckRepositoryGetChildrenPatch(t *testing.T) {
+    db := openTestDB(t)
+    Decks = NewDeckRepository(db)
+
+    ctx := context.Background()
+    parent := insertDeck(t, db, "parent")
+    child := insertDeck(t, db, "child")
+    linkChild(t, db, parent, child)
+
+    deck, err := Decks.Get(ctx, parent)
+    if err != nil {
+        t.Fatalf("Decks.Get: %v", err)
+    }
+
+    if deck.ID != parent || deck.Name != "parent" {
+        t.Fatalf("expected deck {%d parent}, got %+v", parent, deck)
+    }
+
+    children, err := Decks.Children(ctx, parent)
+    if err != nil {
+        t.Fatalf("Decks.Children: %v", err)
+    }
+
+    if len(children) != 1 || children[0].Descendent != child {
+        t.Fatalf("expected one child %d, got %v", child, children)
+    }
+
+    if _, err := Decks.Patch(ctx, parent, &StringMap{"name": "renamed"}); err != nil {
+        t.Fatalf("Decks.Patch: %v", err)
+    }
+
+    patched, err := Decks.Get(ctx, parent)
+    if err != nil {
+        t.Fatalf("Decks.Get after patch: %v", err)
+    }
+
+    if patched.Name != "renamed" {
+        t.Fatalf("expected patched name %q, got %q", "renamed", patched.Name)
+    }
+}